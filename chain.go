@@ -0,0 +1,84 @@
+package crontab
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job 可被JobWrapper包装的任务方法
+type Job func(ctx *Context)
+
+// JobWrapper 对Job进行包装，用于扩展恢复panic、防止重叠执行等通用行为
+type JobWrapper func(Job) Job
+
+// Chain 按顺序组合多个JobWrapper
+type Chain struct {
+	wrappers []JobWrapper
+}
+
+// NewChain 创建一个Chain，wrappers从外到内依次生效，最先添加的最先执行
+func NewChain(wrappers ...JobWrapper) Chain {
+	return Chain{wrappers: wrappers}
+}
+
+// Then 用Chain中的所有JobWrapper包装j
+func (c Chain) Then(j Job) Job {
+	for i := range c.wrappers {
+		j = c.wrappers[len(c.wrappers)-i-1](j)
+	}
+	return j
+}
+
+// Logger 任务执行过程中的日志输出接口
+type Logger interface {
+	Info(msg string, keysAndValues ...interface{})
+	Error(err error, msg string, keysAndValues ...interface{})
+}
+
+// Recover 捕获任务执行期间的panic并交给logger记录，避免一次panic导致整个调度器停止
+func Recover(logger Logger) JobWrapper {
+	return func(j Job) Job {
+		return func(ctx *Context) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error(fmt.Errorf("%v", r), "任务执行期间发生panic")
+				}
+			}()
+			j(ctx)
+		}
+	}
+}
+
+// SkipIfStillRunning 如果上一次调用尚未结束，则跳过本次调用
+func SkipIfStillRunning(logger Logger) JobWrapper {
+	return func(j Job) Job {
+		ch := make(chan struct{}, 1)
+		ch <- struct{}{}
+		return func(ctx *Context) {
+			select {
+			case v := <-ch:
+				defer func() { ch <- v }()
+				j(ctx)
+			default:
+				logger.Info("上一次任务尚未结束，跳过本次调用")
+			}
+		}
+	}
+}
+
+// DelayIfStillRunning 如果上一次调用尚未结束，则等待其结束后再执行本次调用
+func DelayIfStillRunning(logger Logger) JobWrapper {
+	return func(j Job) Job {
+		var mu sync.Mutex
+		return func(ctx *Context) {
+			start := time.Now()
+			mu.Lock()
+			defer mu.Unlock()
+			if wait := time.Since(start); wait > time.Minute {
+				logger.Info("任务等待上一次调用结束", "等待时长", wait)
+			}
+			j(ctx)
+		}
+	}
+}
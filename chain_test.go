@@ -0,0 +1,114 @@
+package crontab
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeLogger struct {
+	mu     sync.Mutex
+	infos  []string
+	errors []string
+}
+
+func (f *fakeLogger) Info(msg string, keysAndValues ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.infos = append(f.infos, msg)
+}
+
+func (f *fakeLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors = append(f.errors, msg)
+}
+
+func TestChainThenOrder(t *testing.T) {
+	var order []string
+	wrap := func(name string) JobWrapper {
+		return func(j Job) Job {
+			return func(ctx *Context) {
+				order = append(order, name)
+				j(ctx)
+			}
+		}
+	}
+	chain := NewChain(wrap("first"), wrap("second"))
+	job := chain.Then(func(ctx *Context) { order = append(order, "job") })
+	job(&Context{})
+	want := []string{"first", "second", "job"}
+	if len(order) != len(want) {
+		t.Fatalf("执行顺序 = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("执行顺序 = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestRecoverSuppressesPanic(t *testing.T) {
+	logger := &fakeLogger{}
+	job := Recover(logger)(func(ctx *Context) { panic("boom") })
+	job(&Context{})
+	if len(logger.errors) != 1 {
+		t.Fatalf("Recover()应该把panic交给logger记录一次，实际记录了%v次", len(logger.errors))
+	}
+}
+
+func TestSkipIfStillRunning(t *testing.T) {
+	logger := &fakeLogger{}
+	release := make(chan struct{})
+	var running int32
+	job := SkipIfStillRunning(logger)(func(ctx *Context) {
+		atomic.AddInt32(&running, 1)
+		<-release
+	})
+
+	go job(&Context{})
+	for atomic.LoadInt32(&running) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	job(&Context{}) // 第一次调用尚未结束，这次应该被跳过
+	close(release)
+
+	if len(logger.infos) != 1 {
+		t.Fatalf("SkipIfStillRunning()应该记录一次跳过，实际记录了%v次", len(logger.infos))
+	}
+}
+
+func TestDelayIfStillRunning(t *testing.T) {
+	logger := &fakeLogger{}
+	var order []string
+	var mu sync.Mutex
+	release := make(chan struct{})
+	job := DelayIfStillRunning(logger)(func(ctx *Context) {
+		mu.Lock()
+		order = append(order, "run")
+		mu.Unlock()
+		<-release
+	})
+
+	go job(&Context{})
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		job(&Context{})
+		close(done)
+	}()
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("DelayIfStillRunning()应该在上一次调用结束后继续执行，而不是永久阻塞")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 2 {
+		t.Fatalf("两次调用都应该执行，实际执行了%v次", len(order))
+	}
+}
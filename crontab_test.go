@@ -0,0 +1,51 @@
+package crontab
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCronRunSkipsMissedFiresAfterClockJump 验证Cron.loop真正消费了注入的now，
+// 时钟被向前调快之后，应该直接顺延到下一个满足规则的时间，而不是把跳过的触发次数都补发
+func TestCronRunSkipsMissedFiresAfterClockJump(t *testing.T) {
+	fc := newFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCron("* * * * * *") // 每秒触发一次
+	c.now = fc.Now
+
+	var fires int32
+	firstFire := make(chan struct{})
+	secondFire := make(chan struct{})
+	c.SetFun(func(ctx *Context) {
+		switch atomic.AddInt32(&fires, 1) {
+		case 1:
+			close(firstFire)
+		case 2:
+			close(secondFire)
+		}
+	})
+
+	go c.Run()
+	defer func() { c.die <- struct{}{} }()
+
+	select {
+	case <-firstFire:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Cron没有按预期完成第一次触发")
+	}
+
+	// 模拟系统时钟被向前调快了10秒（挂起恢复/手动调时等）
+	fc.Advance(10 * time.Second)
+	// 跳变前已经设好的定时器仍会按时完成第二次触发；之后loop发现next落后于调快后的now，
+	// 正确实现应该直接把next顺延到调快后的时间，而不会把跳过的触发次数都补发
+	select {
+	case <-secondFire:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Cron没有按预期完成第二次触发")
+	}
+	// 给真实时钟一点余量，如果被跳过的次数被错误地补发，这段时间内就会立刻观察到fires暴涨
+	time.Sleep(200 * time.Millisecond)
+	if got := atomic.LoadInt32(&fires); got != 2 {
+		t.Fatalf("时钟跳变后观察到fires = %v，期望为2（不应补发跳过的触发次数）", got)
+	}
+}
@@ -0,0 +1,31 @@
+package crontab
+
+import "time"
+
+// EntryID 调度器中任务的唯一标识
+type EntryID int
+
+// Entry 调度器中的一个任务项
+type Entry struct {
+	ID   EntryID            // 任务ID
+	Job  func(ctx *Context) // 任务方法
+	Next time.Time          // 下一次执行时间
+	Prev time.Time          // 上一次执行时间
+
+	rules    string   // 任务规则
+	schedule Schedule // 解析后的规则，用于计算下一次执行时间
+}
+
+// newEntry 根据规则解析出一个任务项
+func newEntry(rules string) (*Entry, error) {
+	schedule, err := Parse(rules)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{rules: rules, schedule: schedule}, nil
+}
+
+// next 计算from之后下一个满足规则的时间点
+func (e *Entry) next(from time.Time) time.Time {
+	return e.schedule.Next(from)
+}
@@ -0,0 +1,385 @@
+package crontab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type cType int
+
+const (
+	second = cType(iota)
+	minute
+	hour
+	day
+	week
+	month
+
+	errorStr        = "规则错误"
+	errorNullYear   = "%v年以前不存在可执行时间"
+	errorStrRange   = "%v的规则错误，范围在%v-%v之间"
+	errorStrCompare = "%v的规则错误，%v-%v开始比结束小"
+	errorStrEvery   = "@every后面的时间间隔格式错误：%v"
+)
+
+var (
+	maxYearLen = 1 // 最长年限多久，今年年份+参数为最大年，0表示限制为今年，-1为不限制(如果周月日不一致可能导致查询几十年以后的)
+
+	// predefinedRules 预定义规则，等效于对应的六位cron规则
+	predefinedRules = map[string]string{
+		"@yearly":   "0 0 0 1 * 1",
+		"@annually": "0 0 0 1 * 1",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * 0 *",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// SetMaxYearLen 设置最长年限多久，今年年份+参数为最大年，0表示限制为今年，-1为不限制(如果周月日不一致可能导致查询几十年以后的)
+func SetMaxYearLen(l int) {
+	maxYearLen = l
+}
+
+func (c cType) text() string {
+	m := map[cType]string{
+		second: "秒",
+		minute: "分",
+		hour:   "时",
+		day:    "天",
+		week:   "周",
+		month:  "月",
+	}
+	return m[c]
+}
+
+// Schedule 描述一个解析后的cron规则，可以计算任意时间点之后下一个满足规则的时间
+type Schedule interface {
+	Next(from time.Time) time.Time
+}
+
+// spec 是Schedule的标准实现，seconds/minutes/.../months为预先计算好的有序可执行数组
+type spec struct {
+	seconds []int
+	minutes []int
+	hours   []int
+	days    []int
+	weeks   []int
+	months  []int
+
+	daysRestricted  bool
+	weeksRestricted bool
+
+	isInterval bool
+	interval   time.Duration
+}
+
+// Parse 解析六位cron规则（支持@yearly等预定义规则和@every固定间隔），返回可复用的Schedule
+func Parse(rules string) (Schedule, error) {
+	expanded := rules
+	if mapped, ok := predefinedRules[expanded]; ok {
+		expanded = mapped
+	} else if strings.HasPrefix(expanded, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimPrefix(expanded, "@every "))
+		if err != nil {
+			return nil, fmt.Errorf(errorStrEvery, err)
+		}
+		return &spec{isInterval: true, interval: interval}, nil
+	}
+	seconds, minutes, hours, days, weeks, months, daysRestricted, weeksRestricted, err := parseRuleFields(expanded)
+	if err != nil {
+		return nil, err
+	}
+	return &spec{
+		seconds:         seconds,
+		minutes:         minutes,
+		hours:           hours,
+		days:            days,
+		weeks:           weeks,
+		months:          months,
+		daysRestricted:  daysRestricted,
+		weeksRestricted: weeksRestricted,
+	}, nil
+}
+
+// Next 计算from之后下一个满足规则的时间，不分配新的可执行数组，只是沿已有数组按字段推进
+func (s *spec) Next(from time.Time) time.Time {
+	if s.isInterval {
+		return from.Add(s.interval)
+	}
+	return matchNext(from, s.seconds, s.minutes, s.hours, s.days, s.weeks, s.months, s.daysRestricted, s.weeksRestricted)
+}
+
+// matchNext 在from之后按月、日/周、时、分、秒逐级对齐查找下一个满足规则的时间点，
+// 字段不满足时直接进位到该字段的下一个候选值，不做索引回溯重试
+func matchNext(from time.Time, seconds, minutes, hours, days, weeks, months []int, daysRestricted, weeksRestricted bool) time.Time {
+	loc := from.Location()
+	limitYear := from.Year() + 1 + maxYearLen
+	t := from.Add(time.Second - time.Duration(from.Nanosecond())*time.Nanosecond)
+	added := false
+
+WRAP:
+	if maxYearLen != -1 && t.Year() >= limitYear {
+		return time.Time{}
+	}
+	for !isInArray(int(t.Month()), months) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !dayMatches(t, days, weeks, daysRestricted, weeksRestricted) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	for !isInArray(t.Hour(), hours) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for !isInArray(t.Minute(), minutes) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	for !isInArray(t.Second(), seconds) {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// dayMatches 判断时间t的天是否满足天/周规则，两者都被显式限制时命中其一即可，否则取交集
+func dayMatches(t time.Time, days, weeks []int, daysRestricted, weeksRestricted bool) bool {
+	dayMatch := isInArray(t.Day(), days)
+	weekMatch := isInArray(int(t.Weekday()), weeks)
+	if daysRestricted && weeksRestricted {
+		return dayMatch || weekMatch
+	}
+	return dayMatch && weekMatch
+}
+
+// parseRuleFields 解析六位cron规则，返回秒分时天周月六个可执行数组，供 Parse 和 Scheduler 共用
+// daysRestricted/weeksRestricted 标记天、周字段是否被显式限制（非"*"也非"?"）
+func parseRuleFields(rules string) (seconds, minutes, hours, days, weeks, months []int, daysRestricted, weeksRestricted bool, err error) {
+	ruleList := strings.Split(rules, " ")
+	if len(ruleList) != 6 {
+		err = errors.New("cron格式为* * * * * *六位，分别代表秒 分 时 天 周 月")
+		return
+	}
+	// 秒
+	secondList := make([]bool, 60)
+	if seconds, err = parseSingle(ruleList[0], second, secondList); err != nil {
+		return
+	}
+	// 分
+	minuteList := make([]bool, 60)
+	if minutes, err = parseSingle(ruleList[1], minute, minuteList); err != nil {
+		return
+	}
+	// 时
+	hourList := make([]bool, 24)
+	if hours, err = parseSingle(ruleList[2], hour, hourList); err != nil {
+		return
+	}
+	// 天，?表示不关心该字段，等同于*
+	dayRule := ruleList[3]
+	daysRestricted = dayRule != "*" && dayRule != "?"
+	if dayRule == "?" {
+		dayRule = "*"
+	}
+	dayList := make([]bool, 32)
+	if days, err = parseSingle(dayRule, day, dayList); err != nil {
+		return
+	}
+	// 周，?表示不关心该字段，等同于*
+	weekRule := ruleList[4]
+	weeksRestricted = weekRule != "*" && weekRule != "?"
+	if weekRule == "?" {
+		weekRule = "*"
+	}
+	weekList := make([]bool, 7)
+	if weeks, err = parseSingle(weekRule, week, weekList); err != nil {
+		return
+	}
+	// 月
+	monthList := make([]bool, 13)
+	if months, err = parseSingle(ruleList[5], month, monthList); err != nil {
+		return
+	}
+	return
+}
+
+func parseSingle(rule string, ct cType, runList []bool) (rs []int, err error) {
+	for _, r := range strings.Split(rule, ",") {
+		if strings.Contains(r, "/") {
+			// 解析每多少时间times
+			rList := strings.Split(r, "/")
+			if len(rList) != 2 {
+				err = errors.New(errorStr)
+				return
+			}
+			var start, end, interval int
+			if interval, err = parseInt(rList[1]); err != nil {
+				return
+			}
+			if err = validNum(interval, ct, true); err != nil {
+				return
+			}
+			start, end = getMaxBetween(ct)
+			if rList[0] != "*" {
+				charList := strings.Split(rList[0], "-")
+				if len(charList) > 2 {
+					err = errors.New(errorStr)
+					return
+				}
+				if start, err = parseField(charList[0], ct); err != nil {
+					return
+				}
+				if err = validNum(start, ct, false); err != nil {
+					return
+				}
+				if len(charList) == 2 {
+					if end, err = parseRangeEnd(charList[1], ct); err != nil {
+						return
+					}
+				}
+			}
+			if start > end {
+				err = fmt.Errorf(errorStrCompare, ct.text(), start, end)
+				return
+			}
+			getTimes(start, end, interval, ct, runList)
+		} else if strings.Contains(r, "-") {
+			// 解释时间段between
+			rList := strings.Split(r, "-")
+			if len(rList) != 2 {
+				err = errors.New(errorStr)
+				return
+			}
+			var start, end, interval int
+			interval = 1
+			if start, err = parseField(rList[0], ct); err != nil {
+				return
+			}
+			if err = validNum(start, ct, false); err != nil {
+				return
+			}
+			if end, err = parseRangeEnd(rList[1], ct); err != nil {
+				return
+			}
+			if start > end {
+				err = fmt.Errorf(errorStrCompare, ct.text(), start, end)
+				return
+			}
+			getTimes(start, end, interval, ct, runList)
+		} else {
+			var start, end, interval int
+			start, end = getMaxBetween(ct)
+			if r == "*" {
+				interval = 1
+				getTimes(start, end, interval, ct, runList)
+				continue
+			}
+			var charNum int
+			if charNum, err = parseField(r, ct); err != nil {
+				return
+			}
+			if err = validNum(charNum, ct, false); err != nil {
+				return
+			}
+			runList[charNum] = true
+		}
+	}
+	for k, v := range runList {
+		if v {
+			rs = append(rs, k)
+		}
+	}
+	return
+}
+
+// getTimes 把start到end（间隔interval）逐个标记进runList，week字段的7会回绕成0（周日）
+func getTimes(start, end, interval int, ct cType, runList []bool) {
+	for i := start; i <= end; i += interval {
+		idx := i
+		if ct == week && idx == 7 {
+			idx = 0
+		}
+		runList[idx] = true
+	}
+}
+
+// parseRangeEnd 解析范围结束端的字段值，week字段的"7"在范围结束位置按7处理（代表回绕到周日），
+// 使"5-7"之类跨过周日的范围能按Fri-Sat-Sun解释，而不是被提前折算成0导致起止颠倒
+func parseRangeEnd(token string, ct cType) (end int, err error) {
+	if ct == week && token == "7" {
+		return 7, nil
+	}
+	if end, err = parseField(token, ct); err != nil {
+		return
+	}
+	err = validNum(end, ct, false)
+	return
+}
+
+func validNum(n int, ct cType, isInterval bool) error {
+	min, max := getMaxBetween(ct)
+	if isInterval && (n < 1 || n > max+1) {
+		return fmt.Errorf(errorStrRange, ct.text()+"间隔", 1, max+1)
+	}
+	if !isInterval && (n < min || n > max) {
+		return fmt.Errorf(errorStrRange, ct.text(), min, max)
+	}
+	return nil
+}
+
+func getMaxBetween(ct cType) (start, end int) {
+	switch ct {
+	case second, minute:
+		start = 0
+		end = 59
+	case hour:
+		start = 0
+		end = 23
+	case day:
+		start = 1
+		end = 31
+	case week:
+		start = 0
+		end = 6
+	case month:
+		start = 1
+		end = 12
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package crontab
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEvery(t *testing.T) {
+	schedule, err := Parse("@every 1h30m")
+	if err != nil {
+		t.Fatalf("Parse(@every) 返回了错误: %v", err)
+	}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	got := schedule.Next(from)
+	want := from.Add(90 * time.Minute)
+	if !got.Equal(want) {
+		t.Fatalf("Next() = %v, want %v", got, want)
+	}
+}
+
+func TestParsePredefinedRules(t *testing.T) {
+	for _, rule := range []string{"@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly"} {
+		if _, err := Parse(rule); err != nil {
+			t.Fatalf("Parse(%v) 返回了错误: %v", rule, err)
+		}
+	}
+}
+
+func TestParseNamedMonthAndWeekTokens(t *testing.T) {
+	schedule, err := Parse("0 0 9 1 * JAN")
+	if err != nil {
+		t.Fatalf("Parse() 返回了错误: %v", err)
+	}
+	from := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	got := schedule.Next(from)
+	want := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("Next() = %v, want %v", got, want)
+	}
+
+	if _, err = Parse("0 0 9 * MON-FRI *"); err != nil {
+		t.Fatalf("Parse(MON-FRI) 返回了错误: %v", err)
+	}
+}
+
+// TestWeekRangeWrapsSundaySynonym 回归测试：5-7（Fri-Sat-Sun）不应因为7被提前折算成0导致start>end报错
+func TestWeekRangeWrapsSundaySynonym(t *testing.T) {
+	schedule, err := Parse("0 0 9 * 5-7 *")
+	if err != nil {
+		t.Fatalf("Parse(5-7) 返回了错误: %v", err)
+	}
+	// 2026-01-01是周四，之后满足5-7（周五/周六/周日）的第一天是周五 2026-01-02
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	got := schedule.Next(from)
+	want := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("Next() = %v, want %v", got, want)
+	}
+}
+
+// TestDayWeekUnionSemantics 天和周字段都显式限制时，命中其一即可触发
+func TestDayWeekUnionSemantics(t *testing.T) {
+	schedule, err := Parse("0 0 9 15 1 *") // 每月15号 或 每周一
+	if err != nil {
+		t.Fatalf("Parse() 返回了错误: %v", err)
+	}
+	// 2026-01-05是周一，不是15号，union语义下应该命中
+	from := time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)
+	got := schedule.Next(from)
+	want := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("Next() = %v, want %v", got, want)
+	}
+}
+
+// TestDayWildcardQuestionMark ?等同于*，不参与天/周的限制判断
+func TestDayWildcardQuestionMark(t *testing.T) {
+	schedule, err := Parse("0 0 9 ? 1 *") // 每周一，天字段不关心
+	if err != nil {
+		t.Fatalf("Parse() 返回了错误: %v", err)
+	}
+	from := time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC) // 周日
+	got := schedule.Next(from)
+	want := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC) // 周一
+	if !got.Equal(want) {
+		t.Fatalf("Next() = %v, want %v", got, want)
+	}
+}
+
+// TestNextMaxYearLenBoundary 天/月字段组合永远无法同时满足时，Next应在maxYearLen年限内放弃并返回零值
+func TestNextMaxYearLenBoundary(t *testing.T) {
+	old := maxYearLen
+	defer SetMaxYearLen(old)
+	SetMaxYearLen(0)
+
+	schedule, err := Parse("0 0 0 30 * 2") // 2月30日永远不存在
+	if err != nil {
+		t.Fatalf("Parse() 返回了错误: %v", err)
+	}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	got := schedule.Next(from)
+	if !got.IsZero() {
+		t.Fatalf("Next() = %v, 期望在maxYearLen年限内找不到可执行时间返回零值", got)
+	}
+}
+
+// TestNextAcrossDST 美国东部2026-03-08凌晨2点钟表跳到3点，Next跨过这次跳变后应该精确落在切换后的本地时间
+func TestNextAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("当前环境缺少时区数据，跳过DST测试: %v", err)
+	}
+	schedule, err := Parse("0 30 3 * * 3") // 每年3月每天3:30
+	if err != nil {
+		t.Fatalf("Parse() 返回了错误: %v", err)
+	}
+	from := time.Date(2026, 3, 7, 20, 0, 0, 0, loc) // 切换前一天晚上，仍是EST
+	got := schedule.Next(from)
+	want := time.Date(2026, 3, 8, 3, 30, 0, 0, loc) // 切换后已经是EDT
+	if !got.Equal(want) {
+		t.Fatalf("Next() = %v, want %v", got, want)
+	}
+}
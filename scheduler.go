@@ -0,0 +1,218 @@
+package crontab
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scheduler 多任务调度器，支持运行时动态增删任务
+type Scheduler struct {
+	entries  []*Entry
+	add      chan *Entry
+	remove   chan EntryID
+	snapshot chan chan []Entry
+	stop     chan chan struct{}
+	running  bool
+	location *time.Location
+	nextID   EntryID
+	chain    Chain
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	now      func() time.Time // 获取当前时间，默认time.Now，测试时可替换为可控的时钟
+}
+
+// New 创建一个多任务调度器
+func New() *Scheduler {
+	return &Scheduler{
+		add:      make(chan *Entry),
+		remove:   make(chan EntryID),
+		snapshot: make(chan chan []Entry),
+		stop:     make(chan chan struct{}),
+		location: time.Local,
+		now:      time.Now,
+	}
+}
+
+// Location 设置调度器使用的时区，需在 Start 之前调用
+func (s *Scheduler) Location(loc *time.Location) {
+	s.location = loc
+}
+
+// WithChain 设置任务注册时应用的包装链，仅对此调用之后AddFunc注册的任务生效
+func (s *Scheduler) WithChain(wrappers ...JobWrapper) {
+	s.chain = NewChain(wrappers...)
+}
+
+// AddFunc 添加一个任务，返回任务ID，调度器运行中调用也是安全的
+func (s *Scheduler) AddFunc(spec string, fn func(ctx *Context)) (EntryID, error) {
+	entry, err := newEntry(spec)
+	if err != nil {
+		return 0, err
+	}
+	entry.Job = s.chain.Then(fn)
+	s.mu.Lock()
+	s.nextID++
+	entry.ID = s.nextID
+	if !s.running {
+		entry.Next = entry.next(s.now().In(s.location))
+		s.entries = append(s.entries, entry)
+		s.mu.Unlock()
+		return entry.ID, nil
+	}
+	s.mu.Unlock()
+	s.add <- entry
+	return entry.ID, nil
+}
+
+// Remove 移除一个任务，调度器运行中调用也是安全的
+func (s *Scheduler) Remove(id EntryID) {
+	s.mu.Lock()
+	if !s.running {
+		s.removeEntry(id)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.remove <- id
+}
+
+// Entries 返回当前所有任务的快照
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if running {
+		ch := make(chan []Entry)
+		s.snapshot <- ch
+		return <-ch
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshotEntries()
+}
+
+// Start 非阻塞启动调度器的后台循环
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	go s.run()
+}
+
+// Stop 结束调度器，返回的 context 会在所有正在执行的任务结束后被取消，如果调度器还未Start则直接返回一个已取消的context
+func (s *Scheduler) Stop() context.Context {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if !running {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+	ch := make(chan struct{})
+	s.stop <- ch
+	<-ch
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		s.wg.Wait()
+		cancel()
+	}()
+	return ctx
+}
+
+func (s *Scheduler) run() {
+	// 加锁读取初始entries，与Start前最后一次AddFunc/Remove的快速路径同步，避免数据竞争
+	s.mu.Lock()
+	now := s.now().In(s.location)
+	for _, e := range s.entries {
+		e.Next = e.next(now)
+	}
+	s.mu.Unlock()
+	for {
+		sort.Sort(byTime(s.entries))
+
+		var timer *time.Timer
+		if len(s.entries) == 0 || s.entries[0].Next.IsZero() {
+			// 没有任务时，使用一个很长的等待时间，等待 add/remove/stop 唤醒
+			timer = time.NewTimer(100000 * time.Hour)
+		} else {
+			timer = time.NewTimer(s.entries[0].Next.Sub(now))
+		}
+
+		select {
+		case now = <-timer.C:
+			now = now.In(s.location)
+			for _, e := range s.entries {
+				if e.Next.After(now) || e.Next.IsZero() {
+					break
+				}
+				s.wg.Add(1)
+				go func(e *Entry, firedAt time.Time) {
+					defer s.wg.Done()
+					defer func() {
+						// 兜底保护，即使没有通过WithChain配置Recover，任务panic也不应导致调度器停止
+						recover()
+					}()
+					e.Job(&Context{s: s, entryID: e.ID, beginTime: firedAt})
+				}(e, e.Next)
+				e.Prev = e.Next
+				e.Next = e.next(now)
+			}
+		case newEntry := <-s.add:
+			timer.Stop()
+			now = s.now().In(s.location)
+			newEntry.Next = newEntry.next(now)
+			s.entries = append(s.entries, newEntry)
+		case id := <-s.remove:
+			timer.Stop()
+			now = s.now().In(s.location)
+			s.removeEntry(id)
+		case replyChan := <-s.snapshot:
+			timer.Stop()
+			replyChan <- s.snapshotEntries()
+			continue
+		case ch := <-s.stop:
+			timer.Stop()
+			ch <- struct{}{}
+			return
+		}
+	}
+}
+
+func (s *Scheduler) removeEntry(id EntryID) {
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		if e.ID != id {
+			entries = append(entries, e)
+		}
+	}
+	s.entries = entries
+}
+
+func (s *Scheduler) snapshotEntries() []Entry {
+	list := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, *e)
+	}
+	return list
+}
+
+// byTime 按下一次执行时间排序，零值（无下一次执行时间）排在最后
+type byTime []*Entry
+
+func (b byTime) Len() int      { return len(b) }
+func (b byTime) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byTime) Less(i, j int) bool {
+	if b[i].Next.IsZero() {
+		return false
+	}
+	if b[j].Next.IsZero() {
+		return true
+	}
+	return b[i].Next.Before(b[j].Next)
+}
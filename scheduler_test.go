@@ -0,0 +1,95 @@
+package crontab
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerAddRemoveEntries(t *testing.T) {
+	s := New()
+	id, err := s.AddFunc("0 0 0 1 1 *", func(ctx *Context) {})
+	if err != nil {
+		t.Fatalf("AddFunc() 返回了错误: %v", err)
+	}
+	entries := s.Entries()
+	if len(entries) != 1 || entries[0].ID != id {
+		t.Fatalf("Entries() = %+v, 期望只包含刚添加的任务 %v", entries, id)
+	}
+
+	s.Remove(id)
+	if entries = s.Entries(); len(entries) != 0 {
+		t.Fatalf("Remove()后Entries() = %+v, 期望为空", entries)
+	}
+}
+
+func TestSchedulerAddFuncInvalidRule(t *testing.T) {
+	s := New()
+	if _, err := s.AddFunc("invalid rule", func(ctx *Context) {}); err == nil {
+		t.Fatal("AddFunc() 对非法规则应该返回错误")
+	}
+}
+
+// TestSchedulerStopBeforeStart 回归测试：未Start就调用Stop不应该永久阻塞
+func TestSchedulerStopBeforeStart(t *testing.T) {
+	s := New()
+	done := make(chan struct{})
+	go func() {
+		ctx := s.Stop()
+		<-ctx.Done()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop() 在未Start时发生了阻塞")
+	}
+}
+
+// TestSchedulerStartUsesInjectedClock 验证AddFunc的未运行快速路径和run()的主循环都实际消费了注入的now，
+// 而不是始终依赖time.Now
+func TestSchedulerStartUsesInjectedClock(t *testing.T) {
+	fc := newFakeClock(time.Now())
+	s := New()
+	s.now = fc.Now
+
+	fired := make(chan struct{})
+	if _, err := s.AddFunc("* * * * * *", func(ctx *Context) {
+		select {
+		case <-fired:
+		default:
+			close(fired)
+		}
+	}); err != nil {
+		t.Fatalf("AddFunc() 返回了错误: %v", err)
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	select {
+	case <-fired:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler没有使用注入的时钟按预期触发任务")
+	}
+}
+
+// TestSchedulerConcurrentStartAndAddFunc 回归测试：Start()与AddFunc()并发调用不应该出现数据竞争
+// （running标记和未运行时的entries快速路径都需要s.mu保护），用go test -race验证
+func TestSchedulerConcurrentStartAndAddFunc(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		s := New()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.Start()
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = s.AddFunc("0 0 0 1 1 *", func(ctx *Context) {})
+		}()
+		wg.Wait()
+		s.Stop()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 )
 
 func parseInt(s string) (int, error) {
@@ -15,20 +16,28 @@ func parseInt(s string) (int, error) {
 	return i, nil
 }
 
-func inSliceByRun(v int, slice []int) (idx int) {
-	n := len(slice)
-	idx = 0
-	for i := 0; i < n; i++ {
-		if slice[i] == v {
-			idx = i
-			return
+// fieldTokens 月份和周的命名token表，month取JAN-DEC，week取SUN-SAT
+var fieldTokens = map[cType]map[string]int{
+	month: {
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	},
+	week: {
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	},
+}
+
+// parseField 解析字段值，month/week字段优先识别命名token（周额外支持7作为周日的同义词），其余按数字解析
+func parseField(s string, ct cType) (int, error) {
+	if tokens, ok := fieldTokens[ct]; ok {
+		if n, ok := tokens[strings.ToUpper(s)]; ok {
+			return n, nil
 		}
-		if slice[i] > v {
-			idx = i
-			return
+		if ct == week && s == "7" {
+			return 0, nil
 		}
 	}
-	return
+	return parseInt(s)
 }
 
 func isInArray(v int, slice []int) bool {